@@ -0,0 +1,294 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Forward - A listener registered against a tunnel; each accepted
+// connection is multiplexed onto its own sub-stream of that tunnel.
+type Forward struct {
+	TunnelID int64
+
+	listener net.Listener
+	mutex    sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+// Close - Stops accepting new connections and severs every connection
+// currently multiplexed through this forward.
+func (f *Forward) Close() {
+	f.listener.Close()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *Forward) track(conn net.Conn) {
+	f.mutex.Lock()
+	f.conns[conn] = struct{}{}
+	f.mutex.Unlock()
+}
+
+func (f *Forward) untrack(conn net.Conn) {
+	f.mutex.Lock()
+	delete(f.conns, conn)
+	f.mutex.Unlock()
+}
+
+// tunnelManager - Registry of active tunnels and the port forwards
+// multiplexed over them.
+type tunnelManager struct {
+	mutex    sync.Mutex
+	tunnels  map[int64]*Tunnel
+	forwards map[int64][]*Forward
+}
+
+// Tunnels - Package-level tunnel registry.
+var Tunnels = &tunnelManager{
+	tunnels:  map[int64]*Tunnel{},
+	forwards: map[int64][]*Forward{},
+}
+
+// Tunnel - Looks up a registered tunnel by ID, or nil if it doesn't exist
+// (already torn down, or never registered).
+func (m *tunnelManager) Tunnel(id int64) *Tunnel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tunnels[id]
+}
+
+// RegisterTunnel - Makes a tunnel visible to Tunnel(id) lookups until it
+// closes. Called by whatever RPC brings the tunnel up in the first place
+// (tunnel create is outside the scope of port forwarding).
+func (m *tunnelManager) RegisterTunnel(tunnel *Tunnel) {
+	m.mutex.Lock()
+	m.tunnels[tunnel.ID] = tunnel
+	m.mutex.Unlock()
+	tunnel.OnClose(func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if m.tunnels[tunnel.ID] == tunnel {
+			delete(m.tunnels, tunnel.ID)
+		}
+	})
+}
+
+// RegisterRemoteForward - Listens on host:port and, for each accepted
+// connection, opens a sub-stream on tunnel and relays bytes in both
+// directions until either side closes. The forward is torn down
+// automatically if the tunnel closes first.
+func (m *tunnelManager) RegisterRemoteForward(tunnelID int64, host string, port int32) (*Forward, error) {
+	tunnel := m.Tunnel(tunnelID)
+	if tunnel == nil {
+		return nil, fmt.Errorf("no such tunnel %d", tunnelID)
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return nil, err
+	}
+	forward := &Forward{TunnelID: tunnelID, listener: listener, conns: map[net.Conn]struct{}{}}
+	m.register(tunnelID, forward)
+	tunnel.OnClose(func() { m.CloseForward(forward) })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			forward.track(conn)
+			go relayRemote(tunnel, conn, forward)
+		}
+	}()
+	return forward, nil
+}
+
+// RegisterSocksListener - Listens on host:port and runs a minimal SOCKS5
+// responder against each accepted connection; once the CONNECT target is
+// known, opens a sub-stream on tunnel, hands the sliver the target, and
+// relays bytes in both directions. The forward is torn down automatically
+// if the tunnel closes first.
+func (m *tunnelManager) RegisterSocksListener(tunnelID int64, host string, port int32) (*Forward, error) {
+	tunnel := m.Tunnel(tunnelID)
+	if tunnel == nil {
+		return nil, fmt.Errorf("no such tunnel %d", tunnelID)
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return nil, err
+	}
+	forward := &Forward{TunnelID: tunnelID, listener: listener, conns: map[net.Conn]struct{}{}}
+	m.register(tunnelID, forward)
+	tunnel.OnClose(func() { m.CloseForward(forward) })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			forward.track(conn)
+			go relaySocks(tunnel, conn, forward)
+		}
+	}()
+	return forward, nil
+}
+
+func (m *tunnelManager) register(tunnelID int64, forward *Forward) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.forwards[tunnelID] = append(m.forwards[tunnelID], forward)
+}
+
+// CloseForward - Tears down a single forward and stops tracking it. Safe to
+// call more than once, or with a nil forward (e.g. a failed registration).
+func (m *tunnelManager) CloseForward(forward *Forward) {
+	if forward == nil {
+		return
+	}
+	forward.Close()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	remaining := m.forwards[forward.TunnelID][:0]
+	for _, f := range m.forwards[forward.TunnelID] {
+		if f != forward {
+			remaining = append(remaining, f)
+		}
+	}
+	m.forwards[forward.TunnelID] = remaining
+}
+
+// relayRemote - Opens a tunnel sub-stream for conn and relays bytes in both
+// directions until either side closes.
+func relayRemote(tunnel *Tunnel, conn net.Conn, forward *Forward) {
+	defer forward.untrack(conn)
+	defer conn.Close()
+	stream, err := tunnel.OpenSubStream()
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+	relay(conn, stream)
+}
+
+// relaySocks - Performs the SOCKS5 handshake on conn, opens a tunnel
+// sub-stream, writes the length-prefixed CONNECT target the sliver dials
+// out to, then relays the rest of the connection. Only the no-auth method
+// and the CONNECT command are supported, which is all proxychains/browsers
+// need.
+func relaySocks(tunnel *Tunnel, conn net.Conn, forward *Forward) {
+	defer forward.untrack(conn)
+	defer conn.Close()
+
+	target, err := socks5Handshake(conn)
+	if err != nil {
+		return
+	}
+	stream, err := tunnel.OpenSubStream()
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	header := make([]byte, 2+len(target))
+	binary.BigEndian.PutUint16(header, uint16(len(target)))
+	copy(header[2:], target)
+	if _, err := stream.Write(header); err != nil {
+		return
+	}
+	relay(conn, stream)
+}
+
+// socks5Handshake - Minimal server-side SOCKS5 handshake: no-auth only,
+// CONNECT only. Returns the requested "host:port" target.
+func socks5Handshake(conn net.Conn) (string, error) {
+	reader := bufio.NewReader(conn)
+
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(reader, greeting); err != nil {
+		return "", err
+	}
+	if greeting[0] != 0x05 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", greeting[0])
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(reader, methods); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{0x05, 0x00}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return "", err
+	}
+	if request[1] != 0x01 {
+		conn.Write([]byte{0x05, 0x07, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return "", fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case 0x01:
+		addr := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(reader, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(reader, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(reader, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	case 0x04:
+		addr := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(reader, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	default:
+		return "", fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(reader, portBytes); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(portBytes)
+
+	if _, err := conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// relay - Copies bytes between a and b until either side closes, then
+// closes both ends.
+func relay(a io.ReadWriteCloser, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+	a.Close()
+	b.Close()
+}
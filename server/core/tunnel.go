@@ -0,0 +1,57 @@
+package core
+
+import (
+	"io"
+	"sync"
+)
+
+// Tunnel - A duplexed byte stream between the server and a single sliver.
+// Port forwards multiplex each forwarded connection onto its own sub-stream
+// over the tunnel's single underlying C2 channel.
+type Tunnel struct {
+	ID int64
+
+	mutex      sync.Mutex
+	nextStream uint64
+	closeHooks []func()
+
+	// openStream asks the sliver to open its end of a new sub-stream and
+	// returns a ReadWriteCloser multiplexed over the tunnel. Wired up by
+	// whatever dispatches this tunnel's reads/writes against the sliver's
+	// C2 channel.
+	openStream func(tunnelID int64, streamID uint64) (io.ReadWriteCloser, error)
+}
+
+// NewTunnel - Constructs a Tunnel multiplexed via openStream.
+func NewTunnel(id int64, openStream func(tunnelID int64, streamID uint64) (io.ReadWriteCloser, error)) *Tunnel {
+	return &Tunnel{ID: id, openStream: openStream}
+}
+
+// OpenSubStream - Opens a new sub-stream multiplexed over this tunnel.
+func (t *Tunnel) OpenSubStream() (io.ReadWriteCloser, error) {
+	t.mutex.Lock()
+	streamID := t.nextStream
+	t.nextStream++
+	t.mutex.Unlock()
+	return t.openStream(t.ID, streamID)
+}
+
+// OnClose - Registers a cleanup hook to run when the tunnel tears down
+// (sliver disconnects, operator closes the tunnel, ...).
+func (t *Tunnel) OnClose(hook func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.closeHooks = append(t.closeHooks, hook)
+}
+
+// Close - Tears the tunnel down, running every registered cleanup hook
+// (including closing any port forwards registered against it).
+func (t *Tunnel) Close() {
+	t.mutex.Lock()
+	hooks := t.closeHooks
+	t.closeHooks = nil
+	t.mutex.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
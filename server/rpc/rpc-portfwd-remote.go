@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"sliver/server/core"
+
+	sliverpb "sliver/protobuf/sliver"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// rpcPortfwdRemote - Asks the sliver to listen on Host:Port inside the
+// target network and pipe each accepted connection back through the tunnel
+// as its own sub-stream; the server side registers a matching listener with
+// core.Tunnels so an operator-side service can reach into the target
+// network. The registered listener is torn down if the sliver never
+// acknowledges the forward, or when the tunnel itself closes.
+//
+// RegisterRemoteForward only knows how to listen on TCP, so a UDP request is
+// rejected here rather than silently handed a non-functional TCP listener.
+func rpcPortfwdRemote(pfwdReq *sliverpb.PortFwdReq, sliver *core.Sliver, tunnel *core.Tunnel, resp RPCResponse) {
+	if pfwdReq.Protocol == sliverpb.PortFwdReq_UDP {
+		resp([]byte{}, errors.New("UDP is not supported for remote port forwards"))
+		return
+	}
+	forward, err := core.Tunnels.RegisterRemoteForward(tunnel.ID, pfwdReq.Host, pfwdReq.Port)
+	if err != nil {
+		resp([]byte{}, err)
+		return
+	}
+	logMsg := fmt.Sprintf("Requesting Sliver %d to listen on %s:%d and relay accepted connections back through tunnel %d", sliver.ID, pfwdReq.Host, pfwdReq.Port, tunnel.ID)
+	requestSliverForward(&sliverpb.PortFwdReq{
+		Direction: sliverpb.PortFwdReq_Remote,
+		Protocol:  pfwdReq.Protocol,
+		Host:      pfwdReq.Host,
+		Port:      pfwdReq.Port,
+		SliverID:  sliver.ID,
+		TunnelID:  tunnel.ID,
+	}, sliver, forward, logMsg, resp)
+}
+
+// rpcPortfwdDynamic - Starts a SOCKS5 responder multiplexed over the tunnel
+// so an operator can point proxychains/a browser at Host:Port and have the
+// sliver dial out to whatever destination each SOCKS client requests.
+// pfwdReq.Protocol is ignored here; SOCKS5 CONNECT is always carried over a
+// TCP sub-stream regardless of what the final destination dial uses.
+func rpcPortfwdDynamic(pfwdReq *sliverpb.PortFwdReq, sliver *core.Sliver, tunnel *core.Tunnel, resp RPCResponse) {
+	forward, err := core.Tunnels.RegisterSocksListener(tunnel.ID, pfwdReq.Host, pfwdReq.Port)
+	if err != nil {
+		resp([]byte{}, err)
+		return
+	}
+	logMsg := fmt.Sprintf("Requesting Sliver %d to act as a SOCKS5 responder for tunnel %d (operator listener on %s:%d)", sliver.ID, tunnel.ID, pfwdReq.Host, pfwdReq.Port)
+	requestSliverForward(&sliverpb.PortFwdReq{
+		Direction: sliverpb.PortFwdReq_Dynamic,
+		SliverID:  sliver.ID,
+		TunnelID:  tunnel.ID,
+	}, sliver, forward, logMsg, resp)
+}
+
+// requestSliverForward - Shared tail end of the Remote/Dynamic handlers:
+// marshal the (already-populated) start request, ask the sliver to start
+// the forward, and tear down the just-registered listener if anything
+// past registration fails.
+func requestSliverForward(startReq *sliverpb.PortFwdReq, sliver *core.Sliver, forward *core.Forward, logMsg string, resp RPCResponse) {
+	startPortFwdReq, err := proto.Marshal(startReq)
+	if err != nil {
+		core.Tunnels.CloseForward(forward)
+		resp([]byte{}, err)
+		return
+	}
+	rpcLog.Info(logMsg)
+	data, err := sliver.Request(sliverpb.MsgPortfwdReq, defaultTimeout, startPortFwdReq)
+	if err != nil {
+		core.Tunnels.CloseForward(forward)
+	}
+	resp(data, err)
+}
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
 	"sliver/server/core"
 
@@ -9,24 +10,50 @@ import (
 	"github.com/golang/protobuf/proto"
 )
 
+// rpcPortfwd - Dispatches a PortFwdReq to the Local/Remote/Dynamic handler
+// based on pfwdReq.Direction (PortFwdReq defaults Direction to Local so
+// existing "portfwd add" callers are unaffected).
 func rpcPortfwd(req []byte, resp RPCResponse) {
 	pfwdReq := &sliverpb.PortFwdReq{}
 	proto.Unmarshal(req, pfwdReq)
 
 	sliver := core.Hive.Sliver(pfwdReq.SliverID)
+	if sliver == nil {
+		resp([]byte{}, errors.New("invalid sliver ID"))
+		return
+	}
 	tunnel := core.Tunnels.Tunnel(pfwdReq.TunnelID)
+	if tunnel == nil {
+		resp([]byte{}, errors.New("invalid tunnel ID"))
+		return
+	}
+
+	switch pfwdReq.Direction {
+	case sliverpb.PortFwdReq_Remote:
+		rpcPortfwdRemote(pfwdReq, sliver, tunnel, resp)
+	case sliverpb.PortFwdReq_Dynamic:
+		rpcPortfwdDynamic(pfwdReq, sliver, tunnel, resp)
+	default:
+		rpcPortfwdLocal(pfwdReq, sliver, tunnel, resp)
+	}
+}
 
+// rpcPortfwdLocal - Classic forward: the sliver dials pfwdReq.Host:Port on
+// behalf of the operator.
+func rpcPortfwdLocal(pfwdReq *sliverpb.PortFwdReq, sliver *core.Sliver, tunnel *core.Tunnel, resp RPCResponse) {
 	startPortFwdReq, err := proto.Marshal(&sliverpb.PortFwdReq{
-		Host:     pfwdReq.Host,
-		Port:     pfwdReq.Port,
-		SliverID: sliver.ID,
-		TunnelID: tunnel.ID,
+		Direction: sliverpb.PortFwdReq_Local,
+		Protocol:  pfwdReq.Protocol,
+		Host:      pfwdReq.Host,
+		Port:      pfwdReq.Port,
+		SliverID:  sliver.ID,
+		TunnelID:  tunnel.ID,
 	})
 	if err != nil {
 		resp([]byte{}, err)
 		return
 	}
-	rpcLog.Info(fmt.Sprintf("Requesting Sliver %d to start a forward rule to %s:%d", sliver.ID, pfwdReq.Host, pfwdReq.Port))
+	rpcLog.Info(fmt.Sprintf("Requesting Sliver %d to start a local forward rule to %s:%d", sliver.ID, pfwdReq.Host, pfwdReq.Port))
 	data, err := sliver.Request(sliverpb.MsgPortfwdReq, defaultTimeout, startPortFwdReq)
 	resp(data, err)
-}
\ No newline at end of file
+}
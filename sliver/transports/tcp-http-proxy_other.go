@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package transports
+
+// {{if .HTTPServer}}
+
+import "net/url"
+
+// proxyFromPAC - WPAD/PAC discovery is only wired up via WinHttpGetProxyForUrl
+// on Windows; elsewhere we rely on http.ProxyFromEnvironment.
+func proxyFromPAC(target *url.URL) (*url.URL, error) {
+	return nil, nil
+}
+
+// {{end}} -HTTPServer
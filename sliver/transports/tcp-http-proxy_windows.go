@@ -0,0 +1,88 @@
+//go:build windows
+// +build windows
+
+package transports
+
+// {{if .HTTPServer}}
+
+import (
+	"errors"
+	"net/url"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modwinhttp                = syscall.NewLazyDLL("winhttp.dll")
+	procWinHTTPOpen           = modwinhttp.NewProc("WinHttpOpen")
+	procWinHTTPGetProxyForURL = modwinhttp.NewProc("WinHttpGetProxyForUrl")
+	procWinHTTPCloseHandle    = modwinhttp.NewProc("WinHttpCloseHandle")
+)
+
+const (
+	winHTTPAutoProxyFlagAutoDetect = 0x00000001
+	winHTTPAutoDetectTypeDHCP      = 0x00000001
+	winHTTPAutoDetectTypeDNSA      = 0x00000002
+	winHTTPAccessTypeNoProxy       = 1
+)
+
+type winHTTPAutoProxyOptions struct {
+	DwFlags                uint32
+	DwAutoDetectFlags      uint32
+	AutoConfigURL          *uint16
+	Reserved1              uintptr
+	Reserved2              uint32
+	FAutoLogonIfChallenged int32
+}
+
+type winHTTPProxyInfo struct {
+	DwAccessType    uint32
+	LpszProxy       *uint16
+	LpszProxyBypass *uint16
+}
+
+// proxyFromPAC - Asks Windows to locate and evaluate a WPAD/PAC script for
+// target, using WinHttpGetProxyForUrl so we inherit the same autoconfig
+// logic as Internet Explorer/Edge instead of re-implementing PAC discovery.
+func proxyFromPAC(target *url.URL) (*url.URL, error) {
+	hSession, _, _ := procWinHTTPOpen.Call(
+		uintptr(0),
+		uintptr(winHTTPAccessTypeNoProxy),
+		uintptr(0),
+		uintptr(0),
+		uintptr(0),
+	)
+	if hSession == 0 {
+		return nil, errors.New("WinHttpOpen failed")
+	}
+	defer procWinHTTPCloseHandle.Call(hSession)
+
+	targetPtr, err := syscall.UTF16PtrFromString(target.String())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := winHTTPAutoProxyOptions{
+		DwFlags:                winHTTPAutoProxyFlagAutoDetect,
+		DwAutoDetectFlags:      winHTTPAutoDetectTypeDHCP | winHTTPAutoDetectTypeDNSA,
+		FAutoLogonIfChallenged: 1,
+	}
+	info := winHTTPProxyInfo{}
+
+	ret, _, callErr := procWinHTTPGetProxyForURL.Call(
+		hSession,
+		uintptr(unsafe.Pointer(targetPtr)),
+		uintptr(unsafe.Pointer(&opts)),
+		uintptr(unsafe.Pointer(&info)),
+	)
+	if ret == 0 {
+		return nil, callErr
+	}
+	if info.LpszProxy == nil {
+		return nil, nil // PAC said to go direct
+	}
+	proxy := syscall.UTF16ToString((*[1 << 16]uint16)(unsafe.Pointer(info.LpszProxy))[:])
+	return url.Parse("http://" + proxy)
+}
+
+// {{end}} -HTTPServer
